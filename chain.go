@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	chainEthereum = "ethereum"
+	chainBitcoin  = "bitcoin"
+	chainCosmos   = "cosmos"
+	chainSolana   = "solana"
+)
+
+// ChainAdapter abstracts fetching the current block height (or equivalent,
+// e.g. a Solana slot) from a single node, so heightsController can compare
+// endpoints without knowing which chain they belong to.
+type ChainAdapter interface {
+	LatestHeight(ctx context.Context) (*big.Int, error)
+	Name() string
+	Endpoint() string
+}
+
+// dialAdapter connects to endpoint and returns the ChainAdapter for chain.
+// An empty chain defaults to ethereum.
+func dialAdapter(chain, endpoint string) (ChainAdapter, error) {
+	switch chain {
+	case "", chainEthereum:
+		c, err := dialClient(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return &ethAdapter{c: c}, nil
+	case chainBitcoin:
+		rc, err := rpc.Dial(normalizeLocalhost(endpoint))
+		if err != nil {
+			return nil, err
+		}
+		return &bitcoinAdapter{rpcClient: rc, endpoint: endpoint}, nil
+	case chainSolana:
+		rc, err := rpc.Dial(normalizeLocalhost(endpoint))
+		if err != nil {
+			return nil, err
+		}
+		return &solanaAdapter{rpcClient: rc, endpoint: endpoint}, nil
+	case chainCosmos:
+		return &cosmosAdapter{endpoint: endpoint, httpClient: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unsupported chain %q", chain)
+	}
+}
+
+// bitcoinAdapter reports height via the Bitcoin Core `getblockcount` RPC.
+type bitcoinAdapter struct {
+	rpcClient *rpc.Client
+	endpoint  string
+}
+
+func (a *bitcoinAdapter) Name() string     { return chainBitcoin }
+func (a *bitcoinAdapter) Endpoint() string { return a.endpoint }
+
+func (a *bitcoinAdapter) LatestHeight(ctx context.Context) (*big.Int, error) {
+	var count int64
+	if err := a.rpcClient.CallContext(ctx, &count, "getblockcount"); err != nil {
+		return nil, err
+	}
+	return big.NewInt(count), nil
+}
+
+// solanaAdapter reports height via the Solana `getSlot` RPC.
+type solanaAdapter struct {
+	rpcClient *rpc.Client
+	endpoint  string
+}
+
+func (a *solanaAdapter) Name() string     { return chainSolana }
+func (a *solanaAdapter) Endpoint() string { return a.endpoint }
+
+func (a *solanaAdapter) LatestHeight(ctx context.Context) (*big.Int, error) {
+	var slot uint64
+	if err := a.rpcClient.CallContext(ctx, &slot, "getSlot"); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(slot), nil
+}
+
+// cosmosAdapter reports height via the Tendermint `/status` REST endpoint.
+type cosmosAdapter struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func (a *cosmosAdapter) Name() string     { return chainCosmos }
+func (a *cosmosAdapter) Endpoint() string { return a.endpoint }
+
+func (a *cosmosAdapter) LatestHeight(ctx context.Context) (*big.Int, error) {
+	url := strings.TrimSuffix(a.endpoint, "/") + "/status"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Result struct {
+			SyncInfo struct {
+				LatestBlockHeight string `json:"latest_block_height"`
+			} `json:"sync_info"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+
+	height, err := strconv.ParseInt(status.Result.SyncInfo.LatestBlockHeight, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cosmosAdapter: parsing latest_block_height: %w", err)
+	}
+	return big.NewInt(height), nil
+}
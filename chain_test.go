@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialAdapter_UnsupportedChain(t *testing.T) {
+	_, err := dialAdapter("dogecoin", "http://10.180.0.2:8545")
+	require.Error(t, err)
+}
+
+func TestDialAdapter_DefaultsToEthereum(t *testing.T) {
+	a, err := dialAdapter("", "http://10.180.0.2:8545")
+	require.NoError(t, err)
+	require.Equal(t, chainEthereum, a.Name())
+}
+
+func TestBitcoinAdapter_LatestHeight(t *testing.T) {
+	server := newFakeJSONRPCNode(t, `{"id":1, "jsonrpc":"2.0", "result":800000}`)
+	defer server.Close()
+
+	a, err := dialAdapter(chainBitcoin, server.URL)
+	require.NoError(t, err)
+
+	height, err := a.LatestHeight(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(800000), height)
+}
+
+func TestSolanaAdapter_LatestHeight(t *testing.T) {
+	server := newFakeJSONRPCNode(t, `{"id":1, "jsonrpc":"2.0", "result":123456789}`)
+	defer server.Close()
+
+	a, err := dialAdapter(chainSolana, server.URL)
+	require.NoError(t, err)
+
+	height, err := a.LatestHeight(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(123456789), height)
+}
+
+func TestCosmosAdapter_LatestHeight(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"result":{"sync_info":{"latest_block_height":"42"}}}`)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	a, err := dialAdapter(chainCosmos, server.URL)
+	require.NoError(t, err)
+
+	height, err := a.LatestHeight(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(42), height)
+}
+
+func newFakeJSONRPCNode(t *testing.T, result string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, result)
+	}))
+}
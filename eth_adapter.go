@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hashAwareAdapter is implemented by chain adapters that can also report
+// block hashes, which is all fork-check needs and currently only ethAdapter
+// provides.
+type hashAwareAdapter interface {
+	ChainAdapter
+	LatestHash(ctx context.Context) (common.Hash, error)
+	HashAtHeight(ctx context.Context, height *big.Int) (common.Hash, error)
+}
+
+// ethAdapter wraps the eth-specific client (polling or subscription-backed)
+// to satisfy ChainAdapter, caching the last polled block so fork-check can
+// read its hash without an extra round trip.
+type ethAdapter struct {
+	c client
+
+	mu        sync.Mutex
+	lastBlock Block
+	polled    bool
+}
+
+func (a *ethAdapter) Name() string     { return chainEthereum }
+func (a *ethAdapter) Endpoint() string { return a.c.Endpoint() }
+
+func (a *ethAdapter) LatestHeight(ctx context.Context) (*big.Int, error) {
+	b, err := a.c.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.lastBlock = b
+	a.polled = true
+	a.mu.Unlock()
+
+	return b.Number.ToInt(), nil
+}
+
+func (a *ethAdapter) LatestHash(ctx context.Context) (common.Hash, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.polled {
+		return common.Hash{}, errors.New("ethAdapter: LatestHeight must be called before LatestHash")
+	}
+	return a.lastBlock.Hash, nil
+}
+
+func (a *ethAdapter) HashAtHeight(ctx context.Context, height *big.Int) (common.Hash, error) {
+	b, err := a.c.BlockByNumber(ctx, height)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return b.Hash, nil
+}
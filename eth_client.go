@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// client is satisfied by both the HTTP polling client and the WebSocket
+// subscription client; LatestBlock is responsible for however each obtains
+// the latest block.
+type client interface {
+	LatestBlock() (Block, error)
+	// BlockByNumber fetches the block at a specific height, used by
+	// fork-check to compare two endpoints at a common height.
+	BlockByNumber(ctx context.Context, number *big.Int) (Block, error)
+	Endpoint() string
+}
+
+// dialClient connects to endpoint, returning a subscription-backed client for
+// ws:// and wss:// endpoints and a polling client for everything else.
+func dialClient(endpoint string) (client, error) {
+	if strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://") {
+		return newSubscriptionClient(endpoint)
+	}
+
+	c, err := rpc.Dial(normalizeLocalhost(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	return &pollingClient{Client: c, endpoint: endpoint}, nil
+}
+
+// pollingClient issues an eth_getBlockByNumber call on every LatestBlock.
+type pollingClient struct {
+	*rpc.Client
+	endpoint string
+}
+
+func (c *pollingClient) Endpoint() string {
+	return c.endpoint
+}
+
+func (c *pollingClient) LatestBlock() (Block, error) {
+	var b Block
+	err := c.Call(&b, "eth_getBlockByNumber", "latest", false)
+	return b, err
+}
+
+func (c *pollingClient) BlockByNumber(ctx context.Context, number *big.Int) (Block, error) {
+	var b Block
+	err := c.CallContext(ctx, &b, "eth_getBlockByNumber", hexutil.EncodeBig(number), false)
+	return b, err
+}
+
+// Block is the subset of an eth_getBlockByNumber response that the
+// comparator and fork-check need.
+type Block struct {
+	Number     hexutil.Big
+	Hash       common.Hash
+	ParentHash common.Hash
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// forkReport describes a detected divergence between two endpoints at a
+// common height.
+type forkReport struct {
+	CommonHeight int64       `json:"common_height"`
+	EndpointA    string      `json:"endpoint_a"`
+	EndpointB    string      `json:"endpoint_b"`
+	HashA        common.Hash `json:"hash_a"`
+	HashB        common.Hash `json:"hash_b"`
+}
+
+// detectFork compares every pair of results for a hash mismatch at a common
+// height, returning the first fork found. Pairs whose adapters don't support
+// hashes (i.e. any non-ethereum chain) are skipped; newHeightsController
+// already rejects fork-check for those chains. When two endpoints report the
+// same height, their hashes are compared directly. When they're within
+// threshold of each other, the newer endpoint is asked for its block at the
+// older endpoint's already-confirmed height so the hashes can be compared at
+// that common height; asking the older (lagging) endpoint for a block at the
+// newer height would query a height it likely hasn't produced yet.
+func (hc *heightsController) detectFork(ctx context.Context, results []endpointResult) (*forkReport, error) {
+	for i := 0; i < len(results); i++ {
+		for j := i + 1; j < len(results); j++ {
+			report, err := hc.detectForkBetween(ctx, results[i], results[j])
+			if err != nil {
+				return nil, err
+			}
+			if report != nil {
+				return report, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (hc *heightsController) detectForkBetween(ctx context.Context, a, b endpointResult) (*forkReport, error) {
+	adapterA, okA := a.adapter.(hashAwareAdapter)
+	adapterB, okB := b.adapter.(hashAwareAdapter)
+	if !okA || !okB {
+		return nil, nil
+	}
+
+	diff := big.NewInt(0).Abs(big.NewInt(0).Sub(a.height, b.height))
+	if diff.Cmp(bigThreshold(hc.threshold)) == 1 {
+		// Too far apart to usefully compare; the height-based modes already
+		// flag this as an outlier.
+		return nil, nil
+	}
+
+	if a.height.Cmp(b.height) == 0 {
+		hashA, err := adapterA.LatestHash(ctx)
+		if err != nil {
+			return nil, err
+		}
+		hashB, err := adapterB.LatestHash(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return forkReportFor(a, b, hashA, hashB, a.height.Int64()), nil
+	}
+
+	older, olderAdapter, newer, newerAdapter := a, adapterA, b, adapterB
+	if a.height.Cmp(b.height) == 1 {
+		older, olderAdapter, newer, newerAdapter = b, adapterB, a, adapterA
+	}
+
+	olderHash, err := olderAdapter.LatestHash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hashAtCommonHeight, err := newerAdapter.HashAtHeight(ctx, older.height)
+	if err != nil {
+		return nil, err
+	}
+	return forkReportFor(older, newer, olderHash, hashAtCommonHeight, older.height.Int64()), nil
+}
+
+func forkReportFor(a, b endpointResult, hashA, hashB common.Hash, commonHeight int64) *forkReport {
+	if hashA == hashB {
+		return nil
+	}
+	return &forkReport{
+		CommonHeight: commonHeight,
+		EndpointA:    a.adapter.Endpoint(),
+		EndpointB:    b.adapter.Endpoint(),
+		HashA:        hashA,
+		HashB:        hashB,
+	}
+}
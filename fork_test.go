@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func polledEthAdapter(c client, b Block) *ethAdapter {
+	return &ethAdapter{c: c, lastBlock: b, polled: true}
+}
+
+func TestHeightsController_DetectFork_SameHeightDifferentHash(t *testing.T) {
+	a := &fakeClient{endpoint: "a.com"}
+	b := &fakeClient{endpoint: "b.com"}
+
+	height := big.NewInt(100)
+	hc := heightsController{threshold: 2}
+	results := []endpointResult{
+		{adapter: polledEthAdapter(a, Block{Hash: common.HexToHash("0x1")}), height: height},
+		{adapter: polledEthAdapter(b, Block{Hash: common.HexToHash("0x2")}), height: height},
+	}
+
+	report, err := hc.detectFork(context.Background(), results)
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	require.Equal(t, int64(100), report.CommonHeight)
+}
+
+func TestHeightsController_DetectFork_NearbyHeightsFetchesCommonHeight(t *testing.T) {
+	older := &fakeClient{endpoint: "older.com"}
+	newer := &fakeClient{endpoint: "newer.com", byNumber: Block{Hash: common.HexToHash("0x1")}}
+
+	hc := heightsController{threshold: 2}
+	results := []endpointResult{
+		{adapter: polledEthAdapter(older, Block{Hash: common.HexToHash("0x2")}), height: big.NewInt(100)},
+		{adapter: polledEthAdapter(newer, Block{Number: hexutil.Big(*big.NewInt(101))}), height: big.NewInt(101)},
+	}
+
+	report, err := hc.detectFork(context.Background(), results)
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	require.Equal(t, int64(100), report.CommonHeight)
+	require.Equal(t, big.NewInt(100), newer.gotNumber)
+}
+
+// TestHeightsController_DetectFork_NearbyHeights_CommonHeightLookupFails
+// covers the newer endpoint failing to return its block at the common
+// height: the error must propagate rather than being silently treated as a
+// successful comparison.
+func TestHeightsController_DetectFork_NearbyHeights_CommonHeightLookupFails(t *testing.T) {
+	older := &fakeClient{endpoint: "older.com"}
+	newer := &fakeClient{endpoint: "newer.com", byNumberErr: errors.New("not found")}
+
+	hc := heightsController{threshold: 2}
+	results := []endpointResult{
+		{adapter: polledEthAdapter(older, Block{Hash: common.HexToHash("0x2")}), height: big.NewInt(100)},
+		{adapter: polledEthAdapter(newer, Block{Number: hexutil.Big(*big.NewInt(101))}), height: big.NewInt(101)},
+	}
+
+	_, err := hc.detectFork(context.Background(), results)
+	require.Error(t, err)
+}
+
+func TestHeightsController_DetectFork_NoForkWhenHashesMatch(t *testing.T) {
+	a := &fakeClient{endpoint: "a.com"}
+	b := &fakeClient{endpoint: "b.com"}
+
+	height := big.NewInt(100)
+	hc := heightsController{threshold: 2}
+	results := []endpointResult{
+		{adapter: polledEthAdapter(a, Block{Hash: common.HexToHash("0x1")}), height: height},
+		{adapter: polledEthAdapter(b, Block{Hash: common.HexToHash("0x1")}), height: height},
+	}
+
+	report, err := hc.detectFork(context.Background(), results)
+	require.NoError(t, err)
+	require.Nil(t, report)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -9,19 +10,26 @@ import (
 	"math/big"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gin-gonic/gin"
 	"github.com/urfave/cli"
 	"go.uber.org/multierr"
 )
 
+const (
+	modeMaxSpread = "max-spread"
+	modeQuorum    = "quorum"
+	modeMajority  = "majority"
+)
+
 func main() {
 	app := cli.NewApp()
-	app.Usage = "CLI for EthBlockComparer: ethblockcomparer <ethereum rpc address 1> <ethereum rpc address 2>"
-	app.Version = "1.0.1"
+	app.Usage = "CLI for EthBlockComparer: ethblockcomparer <node rpc address 1> <node rpc address 2> [<node rpc address N>...]"
+	app.Version = "1.2.0"
 	app.Action = run
 	app.Flags = []cli.Flag{
 		cli.UintFlag{
@@ -33,6 +41,28 @@ func main() {
 			Name:  "insecure",
 			Usage: "If set, skips verification of the server's certificate chain and host name (useful for self-signed certs)",
 		},
+		cli.StringFlag{
+			Name:  "mode, m",
+			Usage: "Comparison mode: max-spread (error if max-min height > threshold), quorum (error unless quorum-size endpoints agree within threshold of the median), or majority (report the plurality height and flag outliers)",
+			Value: modeMaxSpread,
+		},
+		cli.UintFlag{
+			Name:  "quorum-size, q",
+			Usage: "Number of endpoints that must agree within threshold of the median for mode=quorum (defaults to a simple majority of the endpoints)",
+		},
+		cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "If set, poll all endpoints on this interval in the background so /metrics stays fresh without requiring /heights to be called",
+		},
+		cli.BoolFlag{
+			Name:  "fork-check",
+			Usage: "If set, compare block hashes at common heights and return 409 when endpoints are on different chains despite similar heights",
+		},
+		cli.StringFlag{
+			Name:  "chain",
+			Usage: "Chain the endpoints belong to: ethereum, bitcoin, cosmos, or solana. fork-check is only available for ethereum",
+			Value: chainEthereum,
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -44,51 +74,97 @@ func run(c *cli.Context) error {
 	if c.Bool("insecure") {
 		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
-	if c.NArg() != 2 {
-		return errors.New("must pass the correct number of command line arguments, see `help` for more info")
+	if c.NArg() < 2 {
+		return errors.New("must pass at least two endpoints, see `help` for more info")
 	}
-	endpoint1 := c.Args().Get(0)
-	endpoint2 := c.Args().Get(1)
+	endpoints := []string(c.Args())
 	threshold := c.Uint("threshold")
-	r, err := createRouter(endpoint1, endpoint2, threshold)
+	mode := c.String("mode")
+	quorumSize := c.Uint("quorum-size")
+	if quorumSize == 0 {
+		quorumSize = uint(len(endpoints)/2 + 1)
+	}
+	forkCheck := c.Bool("fork-check")
+	chain := c.String("chain")
+
+	r, hc, err := createRouterWithController(endpoints, threshold, mode, quorumSize, forkCheck, chain)
 	if err != nil {
 		return err
 	}
 
-	log.Print("Comparing block heights from ", endpoint1, " and ", endpoint2, ", erroring when difference is greater than ", threshold)
+	if pollInterval := c.Duration("poll-interval"); pollInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go runBackgroundPoller(ctx, hc, pollInterval)
+	}
+
+	log.Print("Comparing block heights from ", strings.Join(endpoints, ", "), " in ", mode, " mode, erroring when difference is greater than ", threshold)
 	if err := r.Run(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func createRouter(p1, p2 string, threshold uint) (*gin.Engine, error) {
+func createRouter(endpoints []string, threshold uint, mode string, quorumSize uint) (*gin.Engine, error) {
+	r, _, err := createRouterWithController(endpoints, threshold, mode, quorumSize, false, chainEthereum)
+	return r, err
+}
+
+// createRouterWithController builds the gin router and also returns the
+// heightsController so callers (e.g. a background poller) can reuse it.
+func createRouterWithController(endpoints []string, threshold uint, mode string, quorumSize uint, forkCheck bool, chain string) (*gin.Engine, *heightsController, error) {
 	r := gin.Default()
-	hc, err := newHeightsController(p1, p2, threshold)
+	hc, err := newHeightsController(endpoints, threshold, mode, quorumSize, forkCheck, chain)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	hc.metrics = newNodeMetrics()
 	r.GET("/heights", hc.Index)
-	return r, err
+	r.GET("/metrics", hc.metrics.handler())
+	return r, hc, nil
 }
 
 type heightsController struct {
-	threshold uint
-	client1   client
-	client2   client
+	threshold  uint
+	mode       string
+	quorumSize uint
+	forkCheck  bool
+	adapters   []ChainAdapter
+	metrics    *nodeMetrics
 }
 
-func newHeightsController(endpoint1, endpoint2 string, threshold uint) (*heightsController, error) {
-	c1, err1 := rpc.Dial(normalizeLocalhost(endpoint1))
-	c2, err2 := rpc.Dial(normalizeLocalhost(endpoint2))
-	merr := multierr.Combine(err1, err2)
+func newHeightsController(endpoints []string, threshold uint, mode string, quorumSize uint, forkCheck bool, chain string) (*heightsController, error) {
+	if len(endpoints) < 2 {
+		return nil, errors.New("must supply at least two endpoints")
+	}
+	switch mode {
+	case modeMaxSpread, modeQuorum, modeMajority:
+	default:
+		return nil, fmt.Errorf("unrecognized mode %q, must be one of %s, %s, %s", mode, modeMaxSpread, modeQuorum, modeMajority)
+	}
+	if forkCheck && chain != chainEthereum {
+		return nil, fmt.Errorf("fork-check is only supported for chain=%s, got %q", chainEthereum, chain)
+	}
+
+	adapters := make([]ChainAdapter, len(endpoints))
+	var merr error
+	for i, endpoint := range endpoints {
+		a, err := dialAdapter(chain, endpoint)
+		merr = multierr.Append(merr, err)
+		if err == nil {
+			adapters[i] = a
+		}
+	}
 	if merr != nil {
 		return nil, merr
 	}
+
 	return &heightsController{
-		threshold: threshold,
-		client1:   &clientImpl{Client: c1, endpoint: endpoint1},
-		client2:   &clientImpl{Client: c2, endpoint: endpoint2},
+		threshold:  threshold,
+		mode:       mode,
+		quorumSize: quorumSize,
+		forkCheck:  forkCheck,
+		adapters:   adapters,
 	}, nil
 }
 
@@ -99,72 +175,209 @@ func normalizeLocalhost(endpoint string) string {
 	return endpoint
 }
 
+// endpointResult is the outcome of polling a single endpoint for its latest height.
+type endpointResult struct {
+	adapter ChainAdapter
+	height  *big.Int
+	latency time.Duration
+}
+
 func (hc *heightsController) Index(c *gin.Context) {
-	var latest1, latest2 block
-	err1 := hc.client1.Call(&latest1, "eth_getBlockByNumber", "latest", false)
-	err2 := hc.client2.Call(&latest2, "eth_getBlockByNumber", "latest", false)
-	merr := multierr.Combine(err1, err2)
-	if merr != nil {
+	results, errs, merr := hc.poll(c.Request.Context())
+
+	// max-spread has no notion of tolerating a bad endpoint: any failure
+	// makes the comparison meaningless, so it hard-fails as before. quorum
+	// and majority are built to tolerate exactly this (a single misbehaving
+	// provider among several), so a failed endpoint there is just another
+	// outlier rather than a reason to abort the whole response.
+	ok, failedEndpoints := splitByError(results, errs)
+	if hc.mode == modeMaxSpread {
+		if merr != nil {
+			c.AbortWithError(http.StatusBadGateway, merr)
+			return
+		}
+	} else if len(ok) == 0 {
 		c.AbortWithError(http.StatusBadGateway, merr)
 		return
 	}
 
-	difference := calculateDifference(latest1, latest2)
-	resp := hc.generateResponse(latest1, latest2, difference)
+	if hc.forkCheck {
+		report, err := hc.detectFork(c.Request.Context(), ok)
+		if err != nil {
+			c.AbortWithError(http.StatusBadGateway, err)
+			return
+		}
+		if report != nil {
+			c.JSON(http.StatusConflict, gin.H{"fork": true, "common_height": report.CommonHeight, "endpoint_a": report.EndpointA, "endpoint_b": report.EndpointB, "hash_a": report.HashA, "hash_b": report.HashB})
+			return
+		}
+	}
+
+	heights := heightsOf(ok)
+	median := calculateMedian(heights)
+	modeHeight := calculateMode(heights)
+
+	status, outliers := hc.evaluate(ok, heights, median, modeHeight)
+	outliers = append(outliers, failedEndpoints...)
+	resp := hc.generateResponse(ok, median, modeHeight, outliers)
 	logJSON(resp)
-	c.JSON(statusCodeForDifference(hc.threshold, difference), resp)
+	c.JSON(status, resp)
+}
+
+// splitByError partitions poll results into the ones that succeeded and the
+// endpoint URLs of the ones that errored, so a caller can evaluate the
+// comparison modes against only the endpoints that actually answered.
+func splitByError(results []endpointResult, errs []error) (ok []endpointResult, failedEndpoints []string) {
+	for i, res := range results {
+		if errs[i] != nil {
+			failedEndpoints = append(failedEndpoints, res.adapter.Endpoint())
+			continue
+		}
+		ok = append(ok, res)
+	}
+	return ok, failedEndpoints
+}
+
+// poll queries every endpoint for its latest height, recording metrics along
+// the way. errs is aligned with results; merr is errs combined for callers
+// that only care whether the poll succeeded as a whole.
+func (hc *heightsController) poll(ctx context.Context) (results []endpointResult, errs []error, merr error) {
+	results = make([]endpointResult, len(hc.adapters))
+	errs = make([]error, len(hc.adapters))
+
+	var wg sync.WaitGroup
+	for i, a := range hc.adapters {
+		wg.Add(1)
+		go func(i int, a ChainAdapter) {
+			defer wg.Done()
+			start := time.Now()
+			height, err := a.LatestHeight(ctx)
+			results[i] = endpointResult{adapter: a, height: height, latency: time.Since(start)}
+			errs[i] = err
+		}(i, a)
+	}
+	wg.Wait()
+
+	if hc.metrics != nil {
+		hc.metrics.record(results, errs)
+	}
+
+	return results, errs, multierr.Combine(errs...)
+}
+
+// evaluate applies the configured comparison mode and returns the HTTP status
+// to respond with along with the endpoints considered outliers.
+func (hc *heightsController) evaluate(results []endpointResult, heights []*big.Int, median, modeHeight *big.Int) (int, []string) {
+	switch hc.mode {
+	case modeQuorum:
+		outliers := outliersOf(hc.threshold, results, median)
+		agree := len(results) - len(outliers)
+		if agree < int(hc.quorumSize) {
+			return http.StatusInternalServerError, outliers
+		}
+		return http.StatusOK, outliers
+	case modeMajority:
+		return http.StatusOK, outliersOf(hc.threshold, results, modeHeight)
+	default:
+		spread := calculateSpread(heights)
+		if bigThreshold(hc.threshold).Cmp(spread) == -1 {
+			return http.StatusInternalServerError, outliersOf(hc.threshold, results, median)
+		}
+		return http.StatusOK, nil
+	}
 }
 
-func (hc *heightsController) generateResponse(latest1, latest2 block, difference *big.Int) gin.H {
+func (hc *heightsController) generateResponse(results []endpointResult, median, modeHeight *big.Int, outliers []string) gin.H {
+	endpoints := make([]interface{}, len(results))
+	for i, res := range results {
+		endpoints[i] = map[string]interface{}{
+			"url":        res.adapter.Endpoint(),
+			"chain":      res.adapter.Name(),
+			"number":     res.height.String(),
+			"latency_ms": res.latency.Milliseconds(),
+		}
+	}
+
 	return gin.H{
-		"difference": difference.String(),
-		"threshold":  fmt.Sprint(hc.threshold),
-		"endpoints": []interface{}{map[string]interface{}{
-			"url":    hc.client1.Endpoint(),
-			"number": latest1.Number,
-		}, map[string]interface{}{
-			"url":    hc.client2.Endpoint(),
-			"number": latest2.Number,
-		}},
+		"mode":        hc.mode,
+		"threshold":   fmt.Sprint(hc.threshold),
+		"median":      median.String(),
+		"mode_height": modeHeight.String(),
+		"outliers":    outliers,
+		"endpoints":   endpoints,
 	}
 }
 
-func calculateDifference(latest1, latest2 block) *big.Int {
-	difference := big.NewInt(0)
-	return difference.Abs(difference.Sub(latest1.Number.ToInt(), latest2.Number.ToInt()))
+func heightsOf(results []endpointResult) []*big.Int {
+	heights := make([]*big.Int, len(results))
+	for i, res := range results {
+		heights[i] = res.height
+	}
+	return heights
 }
 
-func statusCodeForDifference(threshold uint, difference *big.Int) int {
-	bigThresh := big.NewInt(0).SetUint64(uint64(threshold))
-	if bigThresh.Cmp(difference) == -1 {
-		return 500
+// calculateSpread returns the difference between the highest and lowest height.
+func calculateSpread(heights []*big.Int) *big.Int {
+	sorted := sortedCopy(heights)
+	return big.NewInt(0).Sub(sorted[len(sorted)-1], sorted[0])
+}
+
+// calculateMedian returns the median of the given heights.
+func calculateMedian(heights []*big.Int) *big.Int {
+	sorted := sortedCopy(heights)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return big.NewInt(0).Div(big.NewInt(0).Add(sorted[mid-1], sorted[mid]), big.NewInt(2))
 	}
-	return 200
+	return sorted[mid]
 }
 
-func logJSON(v gin.H) {
-	j, err := json.Marshal(v)
-	if err != nil {
-		log.Println("Error: unable to marshal event to JSON")
-		return
+// calculateMode returns the most commonly reported height (the plurality).
+// Ties are broken in favor of the higher height.
+func calculateMode(heights []*big.Int) *big.Int {
+	counts := make(map[string]int, len(heights))
+	best := heights[0]
+	bestCount := 0
+	for _, h := range heights {
+		key := h.String()
+		counts[key]++
+		if counts[key] > bestCount || (counts[key] == bestCount && h.Cmp(best) == 1) {
+			best = h
+			bestCount = counts[key]
+		}
 	}
-	log.Println(string(j))
+	return best
 }
 
-type client interface {
-	Call(result interface{}, method string, args ...interface{}) error
-	Endpoint() string
+func sortedCopy(heights []*big.Int) []*big.Int {
+	sorted := make([]*big.Int, len(heights))
+	copy(sorted, heights)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) == -1 })
+	return sorted
 }
 
-type clientImpl struct {
-	*rpc.Client
-	endpoint string
+// outliersOf returns the endpoint URLs whose reported height differs from
+// reference by more than threshold.
+func outliersOf(threshold uint, results []endpointResult, reference *big.Int) []string {
+	var outliers []string
+	for _, res := range results {
+		diff := big.NewInt(0).Abs(big.NewInt(0).Sub(res.height, reference))
+		if diff.Cmp(bigThreshold(threshold)) == 1 {
+			outliers = append(outliers, res.adapter.Endpoint())
+		}
+	}
+	return outliers
 }
 
-func (c *clientImpl) Endpoint() string {
-	return c.endpoint
+func bigThreshold(threshold uint) *big.Int {
+	return big.NewInt(0).SetUint64(uint64(threshold))
 }
 
-type block struct {
-	Number hexutil.Big // public for deserialization by rpc.Client
+func logJSON(v gin.H) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Error: unable to marshal event to JSON")
+		return
+	}
+	log.Println(string(j))
 }
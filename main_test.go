@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,8 +13,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-gonic/gin"
-	"github.com/golang/mock/gomock"
-	"github.com/smartcontractkit/ethblockcomparer/internal/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,7 +24,7 @@ func TestCreateRouter_Integration(t *testing.T) {
 	fakeEth2, cleanup2 := newFakeEthNode("0x2")
 	defer cleanup2()
 
-	r, err := createRouter(fakeEth1.URL, fakeEth2.URL, threshold)
+	r, err := createRouter([]string{fakeEth1.URL, fakeEth2.URL}, threshold, modeMaxSpread, 0)
 	require.NoError(t, err)
 	server := httptest.NewServer(r)
 	defer server.Close()
@@ -41,23 +40,24 @@ func TestCreateRouter_Integration(t *testing.T) {
 	j := map[string]interface{}{}
 	err = json.Unmarshal(b, &j)
 	require.NoError(t, err)
-	assert.Equal(t, "1", j["difference"])
+	assert.Equal(t, "1", j["median"])
 	assert.Equal(t, "2", j["threshold"])
 }
 
 func TestCreateRouter_Error(t *testing.T) {
 	tests := []struct {
-		name                 string
-		endpoint1, endpoint2 string
-		threshold            uint
+		name      string
+		endpoints []string
+		threshold uint
 	}{
-		{"bad input", "12gibberish", "http://10.180.0.2:8545", 2},
+		{"bad input", []string{"12gibberish", "http://10.180.0.2:8545"}, 2},
+		{"too few endpoints", []string{"http://10.180.0.2:8545"}, 2},
 		// More specific edge cases are covered in TestNewHeightsController
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			_, err := createRouter(test.endpoint1, test.endpoint2, test.threshold)
+			_, err := createRouter(test.endpoints, test.threshold, modeMaxSpread, 0)
 			require.Error(t, err)
 		})
 	}
@@ -65,28 +65,30 @@ func TestCreateRouter_Error(t *testing.T) {
 
 func TestNewHeightsController(t *testing.T) {
 	tests := []struct {
-		name                 string
-		endpoint1, endpoint2 string
-		threshold            uint
-		wantError            bool
+		name      string
+		endpoints []string
+		threshold uint
+		wantError bool
 	}{
-		{"empty endpoint1", "", "http://10.180.0.2:8545", 2, true},
-		{"empty endpoint2", "http://10.180.0.2:8545", "", 2, true},
-		{"bad endpoint1", "12gibberish", "http://10.180.0.2:8545", 2, true},
-		{"bad endpoint2", "http://10.180.0.2:8545", "12gibberish", 2, true},
-		{"good input", "http://10.180.0.2", "http://172.16.0.2:8545", 2, false},
-		{"localhost", "localhost:1234", "http://10.180.0.2:8545", 2, false},
+		{"too few endpoints", []string{"http://10.180.0.2:8545"}, 2, true},
+		{"empty endpoint", []string{"", "http://10.180.0.2:8545"}, 2, true},
+		{"bad endpoint", []string{"12gibberish", "http://10.180.0.2:8545"}, 2, true},
+		{"good input", []string{"http://10.180.0.2", "http://172.16.0.2:8545"}, 2, false},
+		{"localhost", []string{"localhost:1234", "http://10.180.0.2:8545"}, 2, false},
+		{"three endpoints", []string{"http://10.180.0.2", "http://172.16.0.2:8545", "http://172.16.0.3:8545"}, 2, false},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			hc, err := newHeightsController(test.endpoint1, test.endpoint2, test.threshold)
+			hc, err := newHeightsController(test.endpoints, test.threshold, modeMaxSpread, 0, false, chainEthereum)
 			if test.wantError {
 				require.Error(t, err)
 			} else {
 				require.NoError(t, err)
-				assert.Equal(t, test.endpoint1, hc.client1.Endpoint())
-				assert.Equal(t, test.endpoint2, hc.client2.Endpoint())
+				require.Len(t, hc.adapters, len(test.endpoints))
+				for i, endpoint := range test.endpoints {
+					assert.Equal(t, endpoint, hc.adapters[i].Endpoint())
+				}
 				assert.Equal(t, uint(2), hc.threshold)
 			}
 		})
@@ -96,27 +98,29 @@ func TestNewHeightsController(t *testing.T) {
 func TestHeightsController_Index(t *testing.T) {
 	threshold := uint(2)
 	tests := []struct {
-		name               string
-		factory1, factory2 func(*gomock.Controller) *mocks.Mockclient
-		status             int
+		name     string
+		mode     string
+		adapters []ChainAdapter
+		status   int
+		outliers []string
 	}{
-		{"bad client 1", errorClient, goodClient, 502},
-		{"bad client 2", goodClient, errorClient, 502},
-		{"good clients", goodClient, goodClient, 200},
+		{"bad client", modeMaxSpread, adapters(errorClient(), goodClient()), 502, nil},
+		{"good clients agree", modeMaxSpread, adapters(goodClient(), goodClient()), 200, nil},
+		{"quorum met", modeQuorum, adapters(goodClient(), goodClient(), goodClient()), 200, nil},
+		{"quorum tolerates one bad endpoint", modeQuorum, adapters(errorClient(), goodClient(), goodClient()), 200, []string{"errorClient.com"}},
+		{"majority tolerates one bad endpoint", modeMajority, adapters(errorClient(), goodClient(), goodClient()), 200, []string{"errorClient.com"}},
+		{"quorum fails when every endpoint errors", modeQuorum, adapters(errorClient(), errorClient()), 502, nil},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-			mockClient1 := test.factory1(ctrl)
-			mockClient2 := test.factory2(ctrl)
-
 			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/heights", nil)
 			hc := heightsController{
-				threshold: threshold,
-				client1:   mockClient1,
-				client2:   mockClient2,
+				threshold:  threshold,
+				mode:       test.mode,
+				quorumSize: 2,
+				adapters:   test.adapters,
 			}
 
 			hc.Index(c)
@@ -125,84 +129,86 @@ func TestHeightsController_Index(t *testing.T) {
 	}
 }
 
-func TestHeightsController_GenerateResponse(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	hc := heightsController{
-		threshold: 2,
-		client1:   goodClient(ctrl),
-		client2:   goodClient(ctrl),
-	}
-
-	block1 := block{Number: hexutil.Big(*big.NewInt(100))}
-	block2 := block{Number: hexutil.Big(*big.NewInt(99))}
-	diff := calculateDifference(block1, block2)
-	actual := hc.generateResponse(block1, block2, diff)
-	expectation := gin.H{
-		"difference": "1",
-		"threshold":  "2",
-		"endpoints": []interface{}{map[string]interface{}{
-			"url":    hc.client1.Endpoint(),
-			"number": block1.Number,
-		}, map[string]interface{}{
-			"url":    hc.client2.Endpoint(),
-			"number": block2.Number,
-		}},
-	}
-
-	require.Equal(t, expectation, actual)
+func TestNewHeightsController_UnrecognizedMode(t *testing.T) {
+	endpoints := []string{"http://10.180.0.2", "http://172.16.0.2:8545"}
+	_, err := newHeightsController(endpoints, 2, "max_spread", 0, false, chainEthereum)
+	require.Error(t, err)
 }
 
-func TestCalculateDifference(t *testing.T) {
+func TestCalculateMedian(t *testing.T) {
 	tests := []struct {
-		name               string
-		n1, n2, difference int64
+		name    string
+		heights []int64
+		median  int64
 	}{
-		{"good", 2, 1, 1},
-		{"good abs", 1, 2, 1},
+		{"odd", []int64{1, 3, 2}, 2},
+		{"even", []int64{1, 2, 3, 4}, 2},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			block1 := block{Number: hexutil.Big(*big.NewInt(test.n1))}
-			block2 := block{Number: hexutil.Big(*big.NewInt(test.n2))}
-			diff := calculateDifference(block1, block2)
-			require.Equal(t, big.NewInt(test.difference), diff)
+			heights := toBigInts(test.heights)
+			require.Equal(t, big.NewInt(test.median), calculateMedian(heights))
 		})
 	}
 }
 
-func TestStatusCodeForDifference(t *testing.T) {
-	tests := []struct {
-		name        string
-		threshold   uint
-		difference  *big.Int
-		expectation int
-	}{
-		{"inside", 2, big.NewInt(1), 200},
-		{"border", 2, big.NewInt(2), 200},
-		{"outside", 2, big.NewInt(3), 500},
+func TestCalculateMode(t *testing.T) {
+	heights := toBigInts([]int64{1, 2, 2, 3})
+	require.Equal(t, big.NewInt(2), calculateMode(heights))
+}
+
+func TestCalculateSpread(t *testing.T) {
+	heights := toBigInts([]int64{5, 1, 3})
+	require.Equal(t, big.NewInt(4), calculateSpread(heights))
+}
+
+func toBigInts(values []int64) []*big.Int {
+	heights := make([]*big.Int, len(values))
+	for i, v := range values {
+		heights[i] = big.NewInt(v)
 	}
+	return heights
+}
 
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			actual := statusCodeForDifference(test.threshold, test.difference)
-			require.Equal(t, test.expectation, actual)
-		})
+func adapters(clients ...client) []ChainAdapter {
+	as := make([]ChainAdapter, len(clients))
+	for i, c := range clients {
+		as[i] = &ethAdapter{c: c}
 	}
+	return as
 }
 
-func goodClient(ctrl *gomock.Controller) *mocks.Mockclient {
-	mc := mocks.NewMockclient(ctrl)
-	mc.EXPECT().Call(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
-	mc.EXPECT().Endpoint().Return("goodClient.com").AnyTimes()
-	return mc
+func goodClient() client {
+	return &fakeClient{endpoint: "goodClient.com", block: Block{Number: hexutil.Big(*big.NewInt(1))}}
 }
 
-func errorClient(ctrl *gomock.Controller) *mocks.Mockclient {
-	mc := mocks.NewMockclient(ctrl)
-	mc.EXPECT().Call(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("errorClient"))
-	return mc
+func errorClient() client {
+	return &fakeClient{endpoint: "errorClient.com", err: errors.New("errorClient")}
+}
+
+// fakeClient is a hand-rolled client test double. gomock can't help here:
+// the generated Mockclient would live in its own package, and no package
+// other than main can import main to implement a method returning Block -
+// package main isn't importable at all, regardless of what's exported.
+type fakeClient struct {
+	endpoint string
+
+	block Block
+	err   error
+
+	byNumber    Block
+	byNumberErr error
+	gotNumber   *big.Int // last argument BlockByNumber was called with
+}
+
+func (c *fakeClient) Endpoint() string { return c.endpoint }
+
+func (c *fakeClient) LatestBlock() (Block, error) { return c.block, c.err }
+
+func (c *fakeClient) BlockByNumber(ctx context.Context, number *big.Int) (Block, error) {
+	c.gotNumber = number
+	return c.byNumber, c.byNumberErr
 }
 
 func newFakeEthNode(blockHeight string) (*httptest.Server, func()) {
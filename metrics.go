@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// nodeMetrics holds the Prometheus collectors exposed on /metrics. It is
+// backed by its own registry so multiple heightsControllers (e.g. in tests)
+// don't collide on the global default registry.
+type nodeMetrics struct {
+	registry *prometheus.Registry
+
+	blockHeight  *prometheus.GaugeVec
+	pairwiseDiff *prometheus.GaugeVec
+	rpcErrors    *prometheus.CounterVec
+	rpcLatency   *prometheus.HistogramVec
+}
+
+func newNodeMetrics() *nodeMetrics {
+	m := &nodeMetrics{
+		registry: prometheus.NewRegistry(),
+		blockHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ethblockcomparer_block_height",
+			Help: "Latest block height reported by an endpoint",
+		}, []string{"endpoint"}),
+		pairwiseDiff: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ethblockcomparer_pairwise_difference",
+			Help: "Absolute block height difference between a pair of endpoints",
+		}, []string{"endpoint_a", "endpoint_b"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ethblockcomparer_rpc_errors_total",
+			Help: "Count of failed latest-height requests per endpoint",
+		}, []string{"endpoint"}),
+		rpcLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ethblockcomparer_rpc_latency_seconds",
+			Help:    "Latency of latest-height requests per endpoint",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+	}
+	m.registry.MustRegister(m.blockHeight, m.pairwiseDiff, m.rpcErrors, m.rpcLatency)
+	return m
+}
+
+func (m *nodeMetrics) handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return gin.WrapH(h)
+}
+
+// record updates the collectors from a single poll of all endpoints. errs is
+// aligned with results and may contain nils for endpoints that succeeded.
+func (m *nodeMetrics) record(results []endpointResult, errs []error) {
+	for i, res := range results {
+		endpoint := res.adapter.Endpoint()
+		m.rpcLatency.WithLabelValues(endpoint).Observe(res.latency.Seconds())
+		if errs[i] != nil {
+			m.rpcErrors.WithLabelValues(endpoint).Inc()
+			continue
+		}
+		m.blockHeight.WithLabelValues(endpoint).Set(float64(res.height.Int64()))
+	}
+
+	for i := 0; i < len(results); i++ {
+		if errs[i] != nil {
+			continue
+		}
+		for j := i + 1; j < len(results); j++ {
+			if errs[j] != nil {
+				continue
+			}
+			diff := big.NewInt(0).Abs(big.NewInt(0).Sub(results[i].height, results[j].height))
+			f, _ := new(big.Float).SetInt(diff).Float64()
+			m.pairwiseDiff.WithLabelValues(results[i].adapter.Endpoint(), results[j].adapter.Endpoint()).Set(f)
+		}
+	}
+}
+
+// runBackgroundPoller polls every endpoint on the given interval so metrics
+// stay fresh even when nothing is hitting /heights.
+func runBackgroundPoller(ctx context.Context, hc *heightsController, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, _, err := hc.poll(ctx); err != nil {
+				log.Println("background poll error:", err)
+			}
+		}
+	}
+}
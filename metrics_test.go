@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAdapter struct{ endpoint string }
+
+func (f *fakeAdapter) Name() string                                       { return "fake" }
+func (f *fakeAdapter) Endpoint() string                                   { return f.endpoint }
+func (f *fakeAdapter) LatestHeight(ctx context.Context) (*big.Int, error) { return nil, nil }
+
+func TestNodeMetrics_Record(t *testing.T) {
+	m := newNodeMetrics()
+	results := []endpointResult{
+		{adapter: &fakeAdapter{endpoint: "a.com"}, height: big.NewInt(100)},
+		{adapter: &fakeAdapter{endpoint: "b.com"}, height: big.NewInt(98)},
+	}
+	errs := make([]error, len(results))
+
+	m.record(results, errs)
+
+	require.Equal(t, float64(100), testutil.ToFloat64(m.blockHeight.WithLabelValues("a.com")))
+	require.Equal(t, float64(98), testutil.ToFloat64(m.blockHeight.WithLabelValues("b.com")))
+	require.Equal(t, float64(2), testutil.ToFloat64(m.pairwiseDiff.WithLabelValues("a.com", "b.com")))
+}
+
+func TestNodeMetrics_RecordError(t *testing.T) {
+	m := newNodeMetrics()
+	results := []endpointResult{
+		{adapter: &fakeAdapter{endpoint: "a.com"}},
+	}
+	errs := []error{errors.New("boom")}
+
+	m.record(results, errs)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(m.rpcErrors.WithLabelValues("a.com")))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	subscriptionMinBackoff = time.Second
+	subscriptionMaxBackoff = time.Minute
+)
+
+// subscriptionClient keeps the most recently seen block in memory via
+// eth_subscribe("newHeads"), so LatestBlock is a cache read instead of an RPC
+// round trip. It reconnects with exponential backoff if the subscription
+// drops.
+type subscriptionClient struct {
+	endpoint string
+
+	mu        sync.RWMutex
+	latest    Block
+	received  bool
+	rpcClient *rpc.Client // the connection backing the current subscription, for ad hoc calls
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func newSubscriptionClient(endpoint string) (*subscriptionClient, error) {
+	sc := &subscriptionClient{
+		endpoint: endpoint,
+		closeCh:  make(chan struct{}),
+	}
+
+	sub, headers, err := sc.subscribe()
+	if err != nil {
+		return nil, err
+	}
+	go sc.run(sub, headers)
+	return sc, nil
+}
+
+func (sc *subscriptionClient) Endpoint() string {
+	return sc.endpoint
+}
+
+func (sc *subscriptionClient) LatestBlock() (Block, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if !sc.received {
+		return Block{}, errors.New("subscriptionClient: no block received yet")
+	}
+	return sc.latest, nil
+}
+
+func (sc *subscriptionClient) BlockByNumber(ctx context.Context, number *big.Int) (Block, error) {
+	sc.mu.RLock()
+	c := sc.rpcClient
+	sc.mu.RUnlock()
+	if c == nil {
+		return Block{}, errors.New("subscriptionClient: not connected")
+	}
+
+	var b Block
+	err := c.CallContext(ctx, &b, "eth_getBlockByNumber", hexutil.EncodeBig(number), false)
+	return b, err
+}
+
+// subscribe dials the endpoint and subscribes to newHeads, returning the
+// subscription and the channel newly seen blocks are delivered on.
+func (sc *subscriptionClient) subscribe() (*rpc.ClientSubscription, chan Block, error) {
+	c, err := rpc.Dial(sc.endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := make(chan Block)
+	sub, err := c.EthSubscribe(context.Background(), headers, "newHeads")
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	sc.mu.Lock()
+	old := sc.rpcClient
+	sc.rpcClient = c
+	sc.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	return sub, headers, nil
+}
+
+// run consumes newHeads notifications until closed, reconnecting with
+// exponential backoff whenever the subscription errors out. The rpc.Client
+// behind each successful reconnect is closed as soon as subscribe() installs
+// its replacement, so a long-lived subscriptionClient doesn't leak one
+// connection per reconnect.
+func (sc *subscriptionClient) run(sub *rpc.ClientSubscription, headers chan Block) {
+	backoff := subscriptionMinBackoff
+	for {
+		select {
+		case <-sc.closeCh:
+			sub.Unsubscribe()
+			return
+		case b := <-headers:
+			sc.mu.Lock()
+			sc.latest = b
+			sc.received = true
+			sc.mu.Unlock()
+			backoff = subscriptionMinBackoff
+		case err := <-sub.Err():
+			log.Printf("subscriptionClient: %s: subscription dropped (%v), reconnecting in %s", sc.endpoint, err, backoff)
+			select {
+			case <-sc.closeCh:
+				return
+			case <-time.After(backoff):
+			}
+
+			newSub, newHeaders, dialErr := sc.subscribe()
+			if dialErr != nil {
+				backoff = nextBackoff(backoff)
+				continue
+			}
+			sub, headers = newSub, newHeaders
+			backoff = subscriptionMinBackoff
+		}
+	}
+}
+
+func (sc *subscriptionClient) Close() {
+	sc.closeOnce.Do(func() { close(sc.closeCh) })
+}
+
+func nextBackoff(current time.Duration) time.Duration {
+	next := current * 2
+	if next > subscriptionMaxBackoff {
+		return subscriptionMaxBackoff
+	}
+	return next
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionClient_LatestBlockBeforeFirstHeader(t *testing.T) {
+	sc := &subscriptionClient{endpoint: "wss://example.com", closeCh: make(chan struct{})}
+	_, err := sc.LatestBlock()
+	require.Error(t, err)
+}
+
+func TestNextBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  time.Duration
+		expected time.Duration
+	}{
+		{"doubles", time.Second, 2 * time.Second},
+		{"caps at max", subscriptionMaxBackoff, subscriptionMaxBackoff},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, nextBackoff(test.current))
+		})
+	}
+}